@@ -0,0 +1,38 @@
+package main
+
+//
+// start the coordinator process, which is implemented
+// in ../mr/coordinator.go
+//
+// go run mrcoordinator.go pg-*.txt
+// go run mrcoordinator.go -combine pg-*.txt
+//
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"6.5840/mr"
+)
+
+func main() {
+
+	combine := flag.Bool("combine", false, "set if the worker plugin registers a combiner")
+	flag.Parse()
+
+	if flag.NArg() < 1 {
+		fmt.Fprintf(os.Stderr, "Usage: mrcoordinator [-combine] inputfiles...\n")
+		os.Exit(1)
+	}
+
+	m := mr.MakeCoordinator(flag.Args(), 10)
+	m.SetHasCombiner(*combine)
+
+	for m.Done() == false {
+		time.Sleep(time.Second)
+	}
+
+	time.Sleep(time.Second)
+}
@@ -0,0 +1,62 @@
+package main
+
+//
+// start a worker process, which is implemented
+// in ../mr/worker.go. typically there will be
+// multiple worker processes, talking to one coordinator.
+//
+// go run mrworker.go wc.so
+//
+
+import (
+	"fmt"
+	"os"
+	"plugin"
+
+	"6.5840/mr"
+)
+
+func main() {
+
+	if len(os.Args) != 2 {
+		fmt.Fprintf(os.Stderr, "Usage: mrworker xxx.so\n")
+		os.Exit(1)
+	}
+
+	mapf, reducef := loadPlugin(os.Args[1])
+
+	mr.Worker(mapf, reducef)
+}
+
+// load the application Map and Reduce functions from a plugin file, e.g.
+// ../mrapps/wc.so. If the plugin also exports a Combine symbol, it's
+// registered as an optional combiner; unlike Map/Reduce that symbol
+// isn't required, since apps that don't benefit from partial aggregation
+// simply don't define it.
+func loadPlugin(filename string) (func(string, string) []mr.KeyValue, func(string, []string) string) {
+	p, err := plugin.Open(filename)
+	if err != nil {
+		logFatalf("cannot load plugin %v", err)
+	}
+	xmapf, err := p.Lookup("Map")
+	if err != nil {
+		logFatalf("cannot find Map in %v", filename)
+	}
+	mapf := xmapf.(func(string, string) []mr.KeyValue)
+	xreducef, err := p.Lookup("Reduce")
+	if err != nil {
+		logFatalf("cannot find Reduce in %v", filename)
+	}
+	reducef := xreducef.(func(string, []string) string)
+
+	if xcombinef, err := p.Lookup("Combine"); err == nil {
+		mr.RegisterCombiner(xcombinef.(func(string, []string) string))
+	}
+
+	return mapf, reducef
+}
+
+func logFatalf(format string, v ...interface{}) {
+	fmt.Fprintf(os.Stderr, format+"\n", v...)
+	os.Exit(1)
+}
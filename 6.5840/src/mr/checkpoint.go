@@ -0,0 +1,220 @@
+package mr
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+)
+
+//
+// The coordinator's task state lives in memory guarded by locks, which
+// means a crashed coordinator has to restart the whole job from scratch.
+// To let it resume instead, every state-changing branch in GetTask
+// appends a record describing what changed to a write-ahead log on disk.
+// RecoverCoordinator replays that log to rebuild the in-memory state
+// before serving RPCs again.
+//
+
+// compactionInterval is how many checkpoint records accumulate before
+// the log is snapshotted and truncated, so it doesn't grow without bound
+// over a long-running job.
+const compactionInterval = 50
+
+type checkpointOp string
+
+const (
+	opMapDone    checkpointOp = "map_done"
+	opReduceFile checkpointOp = "reduce_file"
+	opReduceDone checkpointOp = "reduce_done"
+	opSnapshot   checkpointOp = "snapshot"
+	// opMapReopen compensates an earlier opMapDone/opReduceFile when a
+	// reaped worker's map output has to be redone: it un-marks the map
+	// done and drops every reduce partition's Locations that came from
+	// it, so replay doesn't resurrect a dead worker's output as done.
+	opMapReopen checkpointOp = "map_reopen"
+)
+
+type checkpointRecord struct {
+	Op       checkpointOp
+	MapId    int                  `json:",omitempty"`
+	ReduceId int                  `json:",omitempty"`
+	Location Location             `json:",omitempty"`
+	Snapshot *coordinatorSnapshot `json:",omitempty"`
+}
+
+// coordinatorSnapshot is the full task state, written during compaction
+// so the log can be truncated back down to one record.
+type coordinatorSnapshot struct {
+	MapDone     []bool
+	ReduceDone  []bool
+	ReduceFiles [][]Location
+}
+
+func defaultCheckpointPath() string {
+	return fmt.Sprintf("%smr-coordinator-%d.wal", os.TempDir()+string(os.PathSeparator), os.Getuid())
+}
+
+// appendCheckpoint writes one WAL record and compacts the log once it's
+// accumulated enough of them. A Coordinator with no open log (e.g. one
+// built by a caller that opted out of checkpointing) silently no-ops.
+func (c *Coordinator) appendCheckpoint(rec checkpointRecord) {
+	if c.log == nil {
+		return
+	}
+
+	data, err := json.Marshal(rec)
+	if err != nil {
+		log.Println("❌ marshal checkpoint record:", err)
+		return
+	}
+	if _, err := fmt.Fprintln(c.log, string(data)); err != nil {
+		log.Println("❌ append checkpoint record:", err)
+		return
+	}
+	c.log.Sync()
+
+	c.writesSinceSnapshot++
+	if c.writesSinceSnapshot >= compactionInterval {
+		c.compact()
+	}
+}
+
+// compact snapshots the full task state and rewrites the log as a single
+// snapshot record, so replay after a crash doesn't have to walk years of
+// history.
+func (c *Coordinator) compact() {
+	snap := c.snapshot()
+
+	tmpPath := c.logPath + ".tmp"
+	tmp, err := os.Create(tmpPath)
+	if err != nil {
+		log.Println("❌ create checkpoint snapshot:", err)
+		return
+	}
+	data, err := json.Marshal(checkpointRecord{Op: opSnapshot, Snapshot: &snap})
+	if err != nil {
+		log.Println("❌ marshal checkpoint snapshot:", err)
+		tmp.Close()
+		return
+	}
+	if _, err := fmt.Fprintln(tmp, string(data)); err != nil {
+		log.Println("❌ write checkpoint snapshot:", err)
+		tmp.Close()
+		return
+	}
+	tmp.Close()
+
+	c.log.Close()
+	if err := os.Rename(tmpPath, c.logPath); err != nil {
+		log.Println("❌ install checkpoint snapshot:", err)
+		return
+	}
+
+	f, err := os.OpenFile(c.logPath, os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		log.Println("❌ reopen checkpoint log:", err)
+		return
+	}
+	c.log = f
+	c.writesSinceSnapshot = 0
+}
+
+func (c *Coordinator) snapshot() coordinatorSnapshot {
+	snap := coordinatorSnapshot{
+		MapDone:     make([]bool, len(c.mapTasks)),
+		ReduceDone:  make([]bool, len(c.reduceTasks)),
+		ReduceFiles: make([][]Location, len(c.reduceTasks)),
+	}
+	for i, t := range c.mapTasks {
+		snap.MapDone[i] = t.isDone
+	}
+	for i, t := range c.reduceTasks {
+		snap.ReduceDone[i] = t.isDone
+		snap.ReduceFiles[i] = t.files
+	}
+	return snap
+}
+
+// replayCheckpoint rebuilds c's task state from an existing WAL, if one
+// exists at logPath. A missing file just means this is a fresh job.
+func replayCheckpoint(c *Coordinator, logPath string) {
+	f, err := os.Open(logPath)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var rec checkpointRecord
+		if err := json.Unmarshal(scanner.Bytes(), &rec); err != nil {
+			log.Println("❌ skip corrupt checkpoint record:", err)
+			continue
+		}
+		applyCheckpoint(c, rec)
+	}
+
+	c.mapLeft = 0
+	for _, t := range c.mapTasks {
+		if !t.isDone {
+			c.mapLeft++
+		}
+	}
+	c.reduceLeft = 0
+	for _, t := range c.reduceTasks {
+		if !t.isDone {
+			c.reduceLeft++
+		}
+	}
+}
+
+func applyCheckpoint(c *Coordinator, rec checkpointRecord) {
+	switch rec.Op {
+	case opSnapshot:
+		if rec.Snapshot == nil {
+			return
+		}
+		for i, done := range rec.Snapshot.MapDone {
+			if i < len(c.mapTasks) {
+				c.mapTasks[i].isDone = done
+			}
+		}
+		for i, done := range rec.Snapshot.ReduceDone {
+			if i < len(c.reduceTasks) {
+				c.reduceTasks[i].isDone = done
+			}
+		}
+		for i, files := range rec.Snapshot.ReduceFiles {
+			if i < len(c.reduceTasks) {
+				c.reduceTasks[i].files = files
+			}
+		}
+	case opMapDone:
+		if rec.MapId < len(c.mapTasks) {
+			c.mapTasks[rec.MapId].isDone = true
+		}
+	case opReduceFile:
+		if rec.ReduceId < len(c.reduceTasks) {
+			c.reduceTasks[rec.ReduceId].files = append(c.reduceTasks[rec.ReduceId].files, rec.Location)
+		}
+	case opReduceDone:
+		if rec.ReduceId < len(c.reduceTasks) {
+			c.reduceTasks[rec.ReduceId].isDone = true
+		}
+	case opMapReopen:
+		if rec.MapId < len(c.mapTasks) {
+			c.mapTasks[rec.MapId].isDone = false
+		}
+		for i, t := range c.reduceTasks {
+			kept := t.files[:0]
+			for _, loc := range t.files {
+				if loc.MapId != rec.MapId {
+					kept = append(kept, loc)
+				}
+			}
+			c.reduceTasks[i].files = kept
+		}
+	}
+}
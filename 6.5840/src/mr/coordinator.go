@@ -1,29 +1,33 @@
 package mr
 
 import (
-	"fmt"
 	"log"
 	"net"
 	"net/http"
 	"net/rpc"
 	"os"
+	"sort"
 	"sync"
 	"time"
 )
 
 
-type MapTasks struct { 
+type MapTasks struct {
 	id int
-	file string 
+	file string
 	startAt time.Time
 	isDone bool
+	// attempt counts how many times this task has been handed out,
+	// including backup copies. 0 means it has never been assigned.
+	attempt int
 }
 
-type ReduceTasks struct { 
-	id int 
-	files []string
+type ReduceTasks struct {
+	id int
+	files []Location
 	startAt time.Time
-	isDone bool 
+	isDone bool
+	attempt int
 }
 
 
@@ -31,9 +35,61 @@ type Coordinator struct {
 	locks sync.Mutex
 	reduceTasks []ReduceTasks
 	mapTasks []MapTasks
-	mapLeft int 
+	mapLeft int
 	reduceLeft int
 
+	// Completion times of finished tasks, used to estimate a median so
+	// stragglers (running well past the median) can be identified for
+	// backup scheduling, per the MR paper's "backup tasks" optimization.
+	mapDurations []time.Duration
+	reduceDurations []time.Duration
+
+	// Write-ahead log used to recover task state after a crash. logPath
+	// is empty and log is nil unless the coordinator was built with
+	// checkpointing enabled.
+	logPath string
+	log *os.File
+	writesSinceSnapshot int
+
+	// hasCombiner is echoed back to workers in every map TaskReply so
+	// they know whether to run their registered combiner.
+	hasCombiner bool
+
+	// nextClientId hands out a unique, persistent id to each worker
+	// process the first time it calls GetTask.
+	nextClientId int
+	// heartbeats tracks the last time each client id was heard from,
+	// either via GetTask or the Heartbeat RPC.
+	heartbeats map[int]time.Time
+	// mapOwner/reduceOwner record which client id is currently running
+	// each task, so a dead worker's tasks can be found and rolled back.
+	mapOwner map[int]int
+	reduceOwner map[int]int
+	// mapOutputOwner records which client id produced each completed map
+	// task's output, since GetTask clears mapOwner once a map is done but
+	// the output still lives on that worker's node and dies with it, so
+	// reapWorker needs somewhere to find it.
+	mapOutputOwner map[int]int
+}
+
+// backupTaskFactor is how much slower than the median completion time a
+// task has to be running before it's considered a straggler.
+const backupTaskFactor = 1.5
+
+// backupTaskThreshold is the fraction of tasks (of a given phase) that
+// must already be done before we start handing out backups at all, so we
+// don't waste workers speculating early when there's no median yet.
+const backupTaskThreshold = 0.8
+
+// median returns the middle value of a set of durations, or 0 if empty.
+func median(durations []time.Duration) time.Duration {
+	if len(durations) == 0 {
+		return 0
+	}
+	sorted := make([]time.Duration, len(durations))
+	copy(sorted, durations)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	return sorted[len(sorted)/2]
 }
 
 /*
@@ -66,101 +122,171 @@ type Coordinator struct {
 */
 func (self *Coordinator) GetTask(args *TaskArgs, reply *TaskReply) error {
 
-	fmt.Println("👁️👁️ Coordinator")
-
 	self.locks.Lock()
 
 	defer self.locks.Unlock()
 
-	//	Task: Map 
+	// Hand out a persistent client id the first time we hear from a
+	// worker, and treat every call as a liveness signal from it.
+	clientId := args.ClientId
+	if clientId == 0 {
+		self.nextClientId += 1
+		clientId = self.nextClientId
+	}
+	self.heartbeats[clientId] = time.Now()
+	reply.ClientId = clientId
+
+	//	Task: Map
 	//
 	// 	Collect intermediate results
 	// 	After the Map tasks have completed, the coordinator collects 
 	// 	the intermediate results generated by each worker node. 
-	if args.WorkerStatus == Map {
-		// 
-		// 	The coordinator groups the intermediate results by key 
+	// args.WorkerId is -1 on a worker's very first call, before it's ever
+	// been handed a task; guard the index so that doesn't get mistaken
+	// for a completion report on map task 0 (WorkerStatus's zero value is
+	// Map, so a fresh TaskArgs{} always looks like one).
+	if args.WorkerStatus == Map && args.WorkerId >= 0 {
+		//
+		// 	The coordinator groups the intermediate results by key
 		// 	and assigns groups of intermediate results to Reduce tasks.
 		//
 		if !self.mapTasks[args.WorkerId].isDone {
 			self.mapTasks[args.WorkerId].isDone = true
+			self.mapDurations = append(self.mapDurations, time.Since(self.mapTasks[args.WorkerId].startAt))
+			self.appendCheckpoint(checkpointRecord{Op: opMapDone, MapId: args.WorkerId})
 			//  Map Task -> Reduce Tasks
-			for id, completedTasks := range args.CompletedTasks { 
-				if len(completedTasks) > 0  { 
-					self.reduceTasks[id].files = append(self.reduceTasks[id].files, completedTasks)
+			//  Each mapper reports back the network location (host + path)
+			//  of every partition it produced, so reducers can pull the
+			//  data directly from the node that made it instead of
+			//  assuming a shared filesystem.
+			for id, loc := range args.CompletedTasks {
+				if loc.Path != "" {
+					self.reduceTasks[id].files = append(self.reduceTasks[id].files, loc)
+					self.appendCheckpoint(checkpointRecord{Op: opReduceFile, ReduceId: id, Location: loc})
 				}
 			}
+			// The task is no longer in flight, but its output now lives
+			// on clientId's node until the reduce phase has fetched it,
+			// so reapWorker still needs to find it there if clientId
+			// dies later.
+			delete(self.mapOwner, args.WorkerId)
+			self.mapOutputOwner[args.WorkerId] = clientId
 			self.mapLeft -=1;
-		} 
+		}
+		// A backup attempt reporting in after the winner already had is a
+		// no-op here; its attempt-scoped files are left behind on that
+		// worker's node, since nothing on the coordinator can reach a
+		// filesystem path on another node to clean them up.
 	}
-	//	Task: Reduce 
+	//	Task: Reduce
 	//	The coordinator updates its records of the Reduce tasks
-	//	by marking it as dne and decrements the number of remaining Reduce Tasks 
+	//	by marking it as dne and decrements the number of remaining Reduce Tasks
 	if args.WorkerStatus == Reduce {
 		if !self.reduceTasks[args.WorkerId].isDone {
 			self.reduceTasks[args.WorkerId].isDone = true
+			self.reduceDurations = append(self.reduceDurations, time.Since(self.reduceTasks[args.WorkerId].startAt))
+			self.appendCheckpoint(checkpointRecord{Op: opReduceDone, ReduceId: args.WorkerId})
+			delete(self.reduceOwner, args.WorkerId)
 			self.reduceLeft -=1
 		}
 	}
 
-	// 	Determine what the next task for the worker should be
-	// 
-	//  Check for remaining Map tasks tasks, and check if the worker has been running 
-	//	running longer than 10 seconds. 
-	//		- If any of them have, it assigns the task to the worker and fills in 
-	//		  `reply` with the necessary information 
+	// 	Determine what the next task for the worker should be.
+	//  A task is up for grabs if nobody currently owns it (either it was
+	//  never assigned, or its previous owner missed enough heartbeats to
+	//  be reaped) or if it's a straggler worth backing up.
 	now := time.Now()
-	timeLeft := now.Add(time.Second * -10)
 
 	// Check for remaining Map tasks left
 	if self.mapLeft > 0 {
-		// Check if the worker has been running longer than 10 seconds
+		// Once most map tasks are done, a task running well past the
+		// median completion time is a straggler worth backing up, per
+		// the paper's "backup tasks" optimization.
+		mapNearingEnd := float64(len(self.mapTasks)-self.mapLeft)/float64(len(self.mapTasks)) >= backupTaskThreshold
+		mapMedian := median(self.mapDurations)
+
 		for MapWorkerId, _ := range self.mapTasks {
 			mapWorker := self.mapTasks[MapWorkerId]
-			// Skip finished workers 
+			// Skip finished workers
 			if mapWorker.isDone { continue }
 
-			// Check if the startTime has been running longer than 10s
-			if mapWorker.startAt.Before(timeLeft) {
-				newTask := TaskReply{
-					WorkerId: mapWorker.id,
-					WorkerStatus: Map,
-					ImpendingTasks: []string{mapWorker.file},
-					NReduce: len(self.reduceTasks),
+			owner, owned := self.mapOwner[MapWorkerId]
+			idle := !owned
+			// A worker that already owns this task and is simply asking
+			// again (its previous reply was lost, or it's reporting an
+			// unrelated completion in the same call) gets handed the same
+			// task back rather than being told there's nothing for it.
+			ownedBySelf := owned && owner == clientId
+			straggling := owned && !ownedBySelf && mapNearingEnd && mapWorker.attempt > 0 && mapMedian > 0 &&
+				now.Sub(mapWorker.startAt) > time.Duration(float64(mapMedian)*backupTaskFactor)
+
+			if idle || straggling || ownedBySelf {
+				if !ownedBySelf {
+					self.mapTasks[MapWorkerId].attempt += 1
+					self.mapTasks[MapWorkerId].startAt = now
+					self.mapOwner[MapWorkerId] = clientId
+				}
+
+				reply.WorkerStatus = Map
+				reply.Map = &MapTaskPayload{
+					InputFile:   mapWorker.file,
+					MapId:       mapWorker.id,
+					NReduce:     len(self.reduceTasks),
+					AttemptId:   self.mapTasks[MapWorkerId].attempt,
+					HasCombiner: self.hasCombiner,
 				}
-				mapWorker.startAt = now
-				
-				reply = &newTask
 				return nil
 			}
 		}
 		// If mapTasks is empty
 		reply.WorkerStatus = Sleep
-	} 
-	
+		reply.Sleep = &SleepPayload{Backoff: time.Second}
+		return nil
+	}
+
 	if self.reduceLeft > 0 {
+		reduceNearingEnd := float64(len(self.reduceTasks)-self.reduceLeft)/float64(len(self.reduceTasks)) >= backupTaskThreshold
+		reduceMedian := median(self.reduceDurations)
+
 		for ReduceWorkerId, _ := range self.reduceTasks {
 			reduceWorker := self.reduceTasks[ReduceWorkerId]
-			// Skip done workers 
+			// Skip done workers
 			if reduceWorker.isDone { continue }
 
-			if reduceWorker.startAt.Before(timeLeft) {
-				reply.ImpendingTasks = reduceWorker.files
-				reply.WorkerId = reduceWorker.id
-				reply.WorkerStatus = Reduce
-
-				reduceWorker.startAt = now
+			owner, owned := self.reduceOwner[ReduceWorkerId]
+			idle := !owned
+			// Same re-request handling as the map loop above: a worker
+			// re-asking for the task it's already running gets it back.
+			ownedBySelf := owned && owner == clientId
+			straggling := owned && !ownedBySelf && reduceNearingEnd && reduceWorker.attempt > 0 && reduceMedian > 0 &&
+				now.Sub(reduceWorker.startAt) > time.Duration(float64(reduceMedian)*backupTaskFactor)
+
+			if idle || straggling || ownedBySelf {
+				if !ownedBySelf {
+					self.reduceOwner[ReduceWorkerId] = clientId
+					self.reduceTasks[ReduceWorkerId].attempt += 1
+					self.reduceTasks[ReduceWorkerId].startAt = now
+				}
 
+				reply.WorkerStatus = Reduce
+				reply.Reduce = &ReduceTaskPayload{
+					PartitionId:     reduceWorker.id,
+					SourceLocations: reduceWorker.files,
+					AttemptId:       self.reduceTasks[ReduceWorkerId].attempt,
+				}
 				return nil
 			}
 		}
 		// If reduceTask is empty
 		reply.WorkerStatus = Sleep
-	} 
-	
+		reply.Sleep = &SleepPayload{Backoff: time.Second}
+		return nil
+	}
+
 	// Terminate itself
 	reply.WorkerStatus = Exit
-	
+	reply.Exit = &ExitPayload{}
+
 	return nil
 }
 
@@ -170,8 +296,6 @@ func (self *Coordinator) GetTask(args *TaskArgs, reply *TaskReply) error {
 //
 func (c *Coordinator) server() {
 
-	fmt.Println("✅ Welcome to this server!!!!!")
-
 	rpc.Register(c)
 	rpc.HandleHTTP()
 	//l, e := net.Listen("tcp", ":1234")
@@ -182,6 +306,7 @@ func (c *Coordinator) server() {
 		log.Fatal("listen error:", e)
 	}
 	go http.Serve(l, nil)
+	go c.watchHeartbeats()
 }
 
 //
@@ -190,8 +315,6 @@ func (c *Coordinator) server() {
 //
 func (c *Coordinator) Done() bool {
 
-	fmt.Println("✅ Coordinator Done!")
-
 	c.locks.Lock()
 	defer c.locks.Unlock()
 	return c.mapLeft == 0 && c.reduceLeft == 0
@@ -215,6 +338,10 @@ func MakeCoordinator(files []string, nReduce int) *Coordinator {
 		reduceTasks: make([]ReduceTasks, nReduce),
 		mapLeft: len(files),
 		reduceLeft: nReduce,
+		heartbeats: make(map[int]time.Time),
+		mapOwner: make(map[int]int),
+		reduceOwner: make(map[int]int),
+		mapOutputOwner: make(map[int]int),
 	}
 
 	// Your code here.
@@ -226,13 +353,75 @@ func MakeCoordinator(files []string, nReduce int) *Coordinator {
 	}
 
 
-	// Initialise Reduce 
-	for idx := 0; idx < nReduce; idx +=1 { 
+	// Initialise Reduce
+	for idx := 0; idx < nReduce; idx +=1 {
 		c.reduceTasks[idx] = ReduceTasks{id: idx, isDone: false}
 	}
 
+	// A fresh job starts a fresh checkpoint log, so it can still recover
+	// if the coordinator dies partway through.
+	logPath := defaultCheckpointPath()
+	f, err := os.Create(logPath)
+	if err != nil {
+		log.Fatalf("❌ open checkpoint log: %v", err)
+	}
+	c.logPath = logPath
+	c.log = f
+
+	c.server()
+	return &c
+}
 
+//
+// SetHasCombiner tells the coordinator whether the job's app registers a
+// combiner, so it can be echoed to workers in every map TaskReply.
+// main/mrcoordinator.go calls this right after MakeCoordinator returns,
+// when the RPC server is already listening; a map task handed out before
+// the call carries HasCombiner false.
+//
+func (c *Coordinator) SetHasCombiner(hasCombiner bool) {
+	c.locks.Lock()
+	defer c.locks.Unlock()
+	c.hasCombiner = hasCombiner
+}
+
+//
+// RecoverCoordinator rebuilds a Coordinator's task state from the
+// checkpoint log at logPath before resuming RPCs, so a crashed
+// coordinator can pick a job back up instead of restarting it from
+// scratch. files and nReduce must match the values the job was
+// originally started with.
+//
+func RecoverCoordinator(logPath string, files []string, nReduce int) *Coordinator {
 
+	if len(files) == 0 {
+		log.Fatalf("❌ Empty Files")
+	}
+
+	c := Coordinator{
+		mapTasks: make([]MapTasks, len(files)),
+		reduceTasks: make([]ReduceTasks, nReduce),
+		heartbeats: make(map[int]time.Time),
+		mapOwner: make(map[int]int),
+		reduceOwner: make(map[int]int),
+		mapOutputOwner: make(map[int]int),
+	}
+
+	for idx, file := range files {
+		c.mapTasks[idx] = MapTasks{id: idx, file: file}
+	}
+	for idx := 0; idx < nReduce; idx +=1 {
+		c.reduceTasks[idx] = ReduceTasks{id: idx}
+	}
+
+	replayCheckpoint(&c, logPath)
+
+	f, err := os.OpenFile(logPath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		log.Fatalf("❌ open checkpoint log: %v", err)
+	}
+	c.logPath = logPath
+	c.log = f
 
 	c.server()
 	return &c
@@ -0,0 +1,141 @@
+package mr
+
+import (
+	"testing"
+	"time"
+)
+
+// newTestCoordinator builds a Coordinator directly, bypassing
+// MakeCoordinator/server() so tests never open a real socket. 2 map
+// tasks feeding 2 reduce tasks is enough to exercise every transition.
+func newTestCoordinator() *Coordinator {
+	return &Coordinator{
+		mapTasks: []MapTasks{
+			{id: 0, file: "a.txt"},
+			{id: 1, file: "b.txt"},
+		},
+		reduceTasks: []ReduceTasks{
+			{id: 0},
+			{id: 1},
+		},
+		mapLeft:        2,
+		reduceLeft:     2,
+		heartbeats:     map[int]time.Time{},
+		mapOwner:       map[int]int{},
+		reduceOwner:    map[int]int{},
+		mapOutputOwner: map[int]int{},
+	}
+}
+
+func TestGetTask_StateTransitions(t *testing.T) {
+	c := newTestCoordinator()
+
+	var clientId int
+	t.Run("first call assigns a map task and a fresh client id", func(t *testing.T) {
+		reply := TaskReply{}
+		// WorkerId -1 mirrors what worker.go sends before it's ever been
+		// handed a task; it must not be mistaken for a report about map
+		// task 0.
+		if err := c.GetTask(&TaskArgs{WorkerId: -1}, &reply); err != nil {
+			t.Fatalf("GetTask: %v", err)
+		}
+		if reply.ClientId == 0 {
+			t.Fatalf("expected a non-zero client id, got 0")
+		}
+		clientId = reply.ClientId
+		if reply.WorkerStatus != Map || reply.Map == nil {
+			t.Fatalf("expected a Map assignment, got status %v, Map %+v", reply.WorkerStatus, reply.Map)
+		}
+		if reply.Map.MapId != 0 {
+			t.Fatalf("expected map task 0, got %d", reply.Map.MapId)
+		}
+	})
+
+	t.Run("reporting map completion hands out the reduce task", func(t *testing.T) {
+		// Finish map 0, then map 1, each feeding partition 0.
+		reply := TaskReply{}
+		if err := c.GetTask(&TaskArgs{ClientId: clientId, WorkerStatus: Map, WorkerId: 0, CompletedTasks: []Location{{MapId: 0, Path: "/mr-0-0-1"}}}, &reply); err != nil {
+			t.Fatalf("GetTask: %v", err)
+		}
+		if reply.WorkerStatus != Map || reply.Map.MapId != 1 {
+			t.Fatalf("expected map task 1 next, got status %v, Map %+v", reply.WorkerStatus, reply.Map)
+		}
+
+		reply = TaskReply{}
+		if err := c.GetTask(&TaskArgs{ClientId: clientId, WorkerStatus: Map, WorkerId: 1, CompletedTasks: []Location{{MapId: 1, Path: "/mr-1-0-1"}}}, &reply); err != nil {
+			t.Fatalf("GetTask: %v", err)
+		}
+		if reply.WorkerStatus != Reduce || reply.Reduce == nil {
+			t.Fatalf("expected a Reduce assignment once every map is done, got status %v", reply.WorkerStatus)
+		}
+		if reply.Reduce.PartitionId != 0 {
+			t.Fatalf("expected reduce partition 0, got %d", reply.Reduce.PartitionId)
+		}
+		if len(c.reduceTasks[0].files) != 2 {
+			t.Fatalf("expected both map outputs collected for partition 0, got %d", len(c.reduceTasks[0].files))
+		}
+	})
+
+	t.Run("a duplicate map completion report is ignored", func(t *testing.T) {
+		reply := TaskReply{}
+		if err := c.GetTask(&TaskArgs{ClientId: clientId, WorkerStatus: Map, WorkerId: 0, CompletedTasks: []Location{{MapId: 0, Path: "/mr-0-0-1"}}}, &reply); err != nil {
+			t.Fatalf("GetTask: %v", err)
+		}
+		if len(c.reduceTasks[0].files) != 2 {
+			t.Fatalf("duplicate report should not append again, got %d files", len(c.reduceTasks[0].files))
+		}
+	})
+
+	t.Run("after reduce completion, the next call returns Exit", func(t *testing.T) {
+		reply := TaskReply{}
+		if err := c.GetTask(&TaskArgs{ClientId: clientId, WorkerStatus: Reduce, WorkerId: 0}, &reply); err != nil {
+			t.Fatalf("GetTask: %v", err)
+		}
+		if reply.WorkerStatus != Reduce || reply.Reduce.PartitionId != 1 {
+			t.Fatalf("expected reduce partition 1 next, got status %v, Reduce %+v", reply.WorkerStatus, reply.Reduce)
+		}
+
+		reply = TaskReply{}
+		if err := c.GetTask(&TaskArgs{ClientId: clientId, WorkerStatus: Reduce, WorkerId: 1}, &reply); err != nil {
+			t.Fatalf("GetTask: %v", err)
+		}
+		if reply.WorkerStatus != Exit || reply.Exit == nil {
+			t.Fatalf("expected Exit once every reduce task is done, got status %v", reply.WorkerStatus)
+		}
+	})
+}
+
+func TestReapWorker_RedoesDoneMapTask(t *testing.T) {
+	c := newTestCoordinator()
+
+	reply := TaskReply{}
+	if err := c.GetTask(&TaskArgs{WorkerId: -1}, &reply); err != nil {
+		t.Fatalf("GetTask: %v", err)
+	}
+	clientId := reply.ClientId
+
+	// The worker finishes map 0 and dies before its heartbeat is ever
+	// missed; its output is now unreachable and must be redone even
+	// though it's marked done.
+	done := TaskReply{}
+	if err := c.GetTask(&TaskArgs{ClientId: clientId, WorkerStatus: Map, WorkerId: 0, CompletedTasks: []Location{{MapId: 0, Path: "/mr-0-0-1"}}}, &done); err != nil {
+		t.Fatalf("GetTask: %v", err)
+	}
+	if !c.mapTasks[0].isDone {
+		t.Fatalf("expected map task 0 to be marked done before reaping")
+	}
+
+	c.locks.Lock()
+	c.reapWorker(clientId)
+	c.locks.Unlock()
+
+	if c.mapTasks[0].isDone {
+		t.Fatalf("expected reaping the client that produced map task 0's output to redo it")
+	}
+	if c.mapLeft != 2 {
+		t.Fatalf("expected mapLeft restored to 2, got %d", c.mapLeft)
+	}
+	if _, owned := c.mapOutputOwner[0]; owned {
+		t.Fatalf("expected map task 0's output ownership cleared after reaping")
+	}
+}
@@ -0,0 +1,124 @@
+package mr
+
+import "time"
+
+// heartbeatInterval is how often a worker calls Heartbeat while it's
+// processing a task.
+const heartbeatInterval = 2 * time.Second
+
+// heartbeatTimeout is how long the coordinator waits without a
+// heartbeat from a worker before declaring it dead.
+const heartbeatTimeout = 10 * time.Second
+
+type HeartbeatArgs struct {
+	ClientId int
+	TaskId   int
+	Phase    WorkerStatus
+}
+
+type HeartbeatReply struct{}
+
+//
+// Heartbeat is called periodically by a worker while it's processing a
+// task. It replaces the old "reassign if the task's startAt is more
+// than 10s old" polling check with an explicit liveness signal, so the
+// coordinator only reassigns work that a worker actually stopped
+// making progress on.
+//
+func (self *Coordinator) Heartbeat(args *HeartbeatArgs, reply *HeartbeatReply) error {
+	self.locks.Lock()
+	defer self.locks.Unlock()
+
+	self.heartbeats[args.ClientId] = time.Now()
+	return nil
+}
+
+//
+// watchHeartbeats runs for the coordinator's lifetime, declaring a
+// worker dead once its heartbeat goes stale and rolling back whatever
+// it was responsible for.
+//
+func (self *Coordinator) watchHeartbeats() {
+	ticker := time.NewTicker(heartbeatInterval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		self.locks.Lock()
+		now := time.Now()
+		for clientId, last := range self.heartbeats {
+			if now.Sub(last) > heartbeatTimeout {
+				self.reapWorker(clientId)
+				delete(self.heartbeats, clientId)
+			}
+		}
+		self.locks.Unlock()
+	}
+}
+
+//
+// reapWorker rolls back every task owned by a dead worker. A mapper's
+// output lives on its own node (see ShuffleTransport), so a map task
+// must be redone even if it was already marked done and reducers may
+// have started pulling from it; a reduce task's output only lands on
+// the shared filesystem once it commits, so an already-done reduce task
+// needs no rollback, only an in-flight one does. self.locks must
+// already be held.
+//
+func (self *Coordinator) reapWorker(clientId int) {
+	// reopenMap redoes a map task whose output died with clientId, whether
+	// it was still in flight or already reported done: the dead worker's
+	// output for it is gone either way, so every Location a reducer was
+	// handed for it is now a dangling pointer to a host that will refuse
+	// the fetch. Dropping them here means the redone map will append
+	// fresh ones once it completes.
+	reopenMap := func(id int) {
+		if self.mapTasks[id].isDone {
+			self.mapLeft++
+		}
+		self.mapTasks[id].isDone = false
+		self.mapTasks[id].startAt = time.Time{}
+
+		for reduceId, reduceTask := range self.reduceTasks {
+			kept := reduceTask.files[:0]
+			for _, loc := range reduceTask.files {
+				if loc.MapId != id {
+					kept = append(kept, loc)
+				}
+			}
+			self.reduceTasks[reduceId].files = kept
+		}
+
+		// Compensate the earlier opMapDone/opReduceFile records so a
+		// crash after this point doesn't replay this map back to done
+		// with Locations pointing at a worker that's gone.
+		self.appendCheckpoint(checkpointRecord{Op: opMapReopen, MapId: id})
+	}
+
+	for id, owner := range self.mapOwner {
+		if owner == clientId {
+			reopenMap(id)
+			delete(self.mapOwner, id)
+		}
+	}
+	// A map task that already finished is no longer in mapOwner (GetTask
+	// clears that on completion), but its output still lives on the
+	// worker that produced it and dies with it, so it has to be redone
+	// too — this is the "map tasks ... must be re-run even if previously
+	// marked done" case from the paper.
+	for id, owner := range self.mapOutputOwner {
+		if owner == clientId {
+			reopenMap(id)
+			delete(self.mapOutputOwner, id)
+		}
+	}
+
+	for id, owner := range self.reduceOwner {
+		if owner != clientId {
+			continue
+		}
+		if !self.reduceTasks[id].isDone {
+			self.reduceTasks[id].startAt = time.Time{}
+		}
+		delete(self.reduceOwner, id)
+	}
+}
@@ -0,0 +1,105 @@
+package mr
+
+import (
+	"os"
+	"strconv"
+	"time"
+)
+
+//
+// RPC definitions.
+//
+// remember to capitalize all names.
+//
+
+type WorkerStatus int
+
+const (
+	Map WorkerStatus = iota
+	Reduce
+	Sleep
+	Exit
+)
+
+// Location describes where one partition of a map task's intermediate
+// output lives, so a reducer knows which node to pull it from instead of
+// assuming a shared filesystem.
+type Location struct {
+	MapId int
+	Host  string
+	Path  string
+}
+
+type TaskArgs struct {
+	// ClientId identifies the calling worker process across its whole
+	// lifetime, distinct from WorkerId which is a task id. 0 means "I
+	// don't have one yet", and the coordinator hands one out.
+	ClientId       int
+	WorkerId       int
+	WorkerStatus   WorkerStatus
+	AttemptId      int
+	CompletedTasks []Location
+}
+
+// MapTaskPayload is a map task's assignment: which file to read and how
+// many reduce partitions to split its output into.
+type MapTaskPayload struct {
+	InputFile string
+	MapId     int
+	NReduce   int
+	// AttemptId distinguishes backup (speculative) copies of the same
+	// task from one another so intermediate output doesn't collide and
+	// a losing attempt's files can be identified and cleaned up.
+	AttemptId int
+	// HasCombiner tells the worker whether to run its registered
+	// combiner over each partition before writing it out.
+	HasCombiner bool
+}
+
+// ReduceTaskPayload is a reduce task's assignment: which partition it
+// owns and where every mapper put that partition's data.
+type ReduceTaskPayload struct {
+	PartitionId     int
+	SourceLocations []Location
+	AttemptId       int
+}
+
+// SleepPayload tells a worker there's no work for it right now, and how
+// long to back off before asking again.
+type SleepPayload struct {
+	Backoff time.Duration
+}
+
+// ExitPayload tells a worker the job is done and it should shut down.
+type ExitPayload struct{}
+
+//
+// TaskReply is a discriminated union: WorkerStatus is the tag, and
+// exactly one of Map/Reduce/Sleep/Exit is non-nil depending on it. This
+// replaces a flat struct that conflated map inputs and reduce inputs in
+// one ImpendingTasks []string field, which made a map assignment with no
+// NReduce (or a reduce assignment with no source locations) a state the
+// type system let you construct by mistake.
+//
+type TaskReply struct {
+	// ClientId is filled in with a freshly generated id the first time a
+	// worker calls GetTask with ClientId 0, and echoed back unchanged
+	// after that.
+	ClientId     int
+	WorkerStatus WorkerStatus
+
+	Map    *MapTaskPayload
+	Reduce *ReduceTaskPayload
+	Sleep  *SleepPayload
+	Exit   *ExitPayload
+}
+
+// Cook up a unique-ish UNIX-domain socket name
+// in /var/tmp, for the coordinator.
+// Can't use the current directory since
+// Athena AFS doesn't support UNIX-domain sockets.
+func coordinatorSock() string {
+	s := "/var/tmp/5840-mr-"
+	s += strconv.Itoa(os.Getuid())
+	return s
+}
@@ -0,0 +1,104 @@
+package mr
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+)
+
+//
+// ShuffleTransport fetches a reduce input partition described by a
+// Location and returns a path on the local filesystem that the reducer
+// can open directly. Implementations decide how the bytes actually move:
+// a shared filesystem can just hand the path back, while a networked
+// worker has to pull the bytes over the wire from wherever the producing
+// mapper put them, per the original paper's locality-aware shuffle.
+//
+type ShuffleTransport interface {
+	Fetch(loc Location) (string, error)
+}
+
+//
+// LocalTransport assumes every worker sees the same filesystem (e.g. all
+// running on one machine, or sharing an NFS mount), so fetching a
+// partition is just handing back the path the mapper already wrote to.
+//
+type LocalTransport struct{}
+
+func (LocalTransport) Fetch(loc Location) (string, error) {
+	if _, err := os.Stat(loc.Path); err != nil {
+		return "", fmt.Errorf("❌ local shuffle: %w", err)
+	}
+	return loc.Path, nil
+}
+
+//
+// HTTPTransport pulls a partition from the mapper's node over HTTP. Every
+// worker serves its own intermediate files (see ServeIntermediateFiles
+// below, started by Worker) so reducers can reach directly into the producing mapper's
+// node instead of round-tripping through the coordinator.
+//
+type HTTPTransport struct {
+	// Dir is where fetched partitions are cached locally.
+	Dir string
+}
+
+func (t HTTPTransport) Fetch(loc Location) (string, error) {
+	url := fmt.Sprintf("http://%s%s", loc.Host, loc.Path)
+
+	resp, err := http.Get(url)
+	if err != nil {
+		return "", fmt.Errorf("❌ http shuffle fetch %s: %w", url, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("❌ http shuffle fetch %s: status %d", url, resp.StatusCode)
+	}
+
+	dst := filepath.Join(t.Dir, fmt.Sprintf("mr-shuffle-%d%s", loc.MapId, filepath.Ext(loc.Path)))
+	out, err := os.Create(dst)
+	if err != nil {
+		return "", err
+	}
+	defer out.Close()
+
+	if _, err := io.Copy(out, resp.Body); err != nil {
+		return "", err
+	}
+	return dst, nil
+}
+
+//
+// pickTransport chooses how to fetch a partition based on where it
+// lives: a location with no host (or one that matches this worker) is
+// read straight off disk, everything else goes over HTTP.
+//
+func pickTransport(loc Location, selfHost string, cacheDir string) ShuffleTransport {
+	if loc.Host == "" || loc.Host == selfHost {
+		return LocalTransport{}
+	}
+	return HTTPTransport{Dir: cacheDir}
+}
+
+//
+// ServeIntermediateFiles starts an HTTP file server rooted at dir so
+// other workers can pull this worker's map output over HTTPTransport.
+// It returns the listener's address ("host:port") for the Location.Host
+// field. The listener binds ":0", so the host part is the wildcard
+// address (e.g. "[::]"), which dials back to the local machine: workers
+// on other machines cannot reach this server through it.
+//
+func ServeIntermediateFiles(dir string) (string, error) {
+	l, err := net.Listen("tcp", ":0")
+	if err != nil {
+		return "", err
+	}
+	mux := http.NewServeMux()
+	mux.Handle("/", http.FileServer(http.Dir(dir)))
+	go http.Serve(l, mux)
+	return l.Addr().String(), nil
+}
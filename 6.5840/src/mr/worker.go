@@ -0,0 +1,321 @@
+package mr
+
+import (
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"io/ioutil"
+	"net/rpc"
+	"os"
+	"sort"
+	"time"
+)
+
+//
+// Map functions return a slice of KeyValue.
+//
+type KeyValue struct {
+	Key   string
+	Value string
+}
+
+// use ihash(key) % NReduce to choose the reduce
+// task number for each KeyValue emitted by Map.
+func ihash(key string) int {
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	return int(h.Sum32() & 0x7fffffff)
+}
+
+// registeredCombiner is set by RegisterCombiner. When present and the
+// coordinator says the job wants it (MapTaskPayload.HasCombiner), doMap
+// runs it over each partition's output before writing intermediate
+// files, to cut shuffle volume for associative/commutative reducers like
+// word count.
+var registeredCombiner func(key string, values []string) string
+
+// RegisterCombiner installs an optional combiner for the running worker.
+// main/mrworker.go calls this after loading the app plugin, if the
+// plugin exports a Combine symbol.
+func RegisterCombiner(combiner func(key string, values []string) string) {
+	registeredCombiner = combiner
+}
+
+// applyCombiner groups a partition's key/value pairs by key and reduces
+// each group down to a single pair via the registered combiner. It's a
+// no-op if no combiner is registered.
+func applyCombiner(bucket []KeyValue) []KeyValue {
+	if registeredCombiner == nil {
+		return bucket
+	}
+
+	sort.Slice(bucket, func(i, j int) bool { return bucket[i].Key < bucket[j].Key })
+
+	var combined []KeyValue
+	i := 0
+	for i < len(bucket) {
+		j := i + 1
+		for j < len(bucket) && bucket[j].Key == bucket[i].Key {
+			j++
+		}
+		values := make([]string, 0, j-i)
+		for k := i; k < j; k++ {
+			values = append(values, bucket[k].Value)
+		}
+		combined = append(combined, KeyValue{Key: bucket[i].Key, Value: registeredCombiner(bucket[i].Key, values)})
+		i = j
+	}
+	return combined
+}
+
+//
+// main/mrworker.go calls this function.
+//
+func Worker(mapf func(string, string) []KeyValue, reducef func(string, []string) string) {
+
+	workDir, err := os.Getwd()
+	if err != nil {
+		logFatal("❌ getwd", err)
+	}
+
+	// Serve our own intermediate files so reducers can pull directly from
+	// us over HTTP instead of a shared filesystem. The advertised address
+	// is the wildcard listener address, so only reducers on this machine
+	// can reach it.
+	selfHost, err := ServeIntermediateFiles(workDir)
+	if err != nil {
+		logFatal("❌ ServeIntermediateFiles", err)
+	}
+
+	clientId := 0
+	taskId := -1
+
+	for {
+		reply := callGetTask(clientId, taskId, nil)
+		if reply == nil {
+			return
+		}
+		clientId = reply.ClientId
+
+		switch reply.WorkerStatus {
+		case Map:
+			taskId = reply.Map.MapId
+			stopHeartbeat := startHeartbeat(clientId, taskId, Map)
+			completed := doMap(mapf, reply.Map, selfHost, workDir)
+			stopHeartbeat()
+			callGetTaskAttempt(clientId, taskId, reply.Map.AttemptId, completed)
+			// Already reported; don't send this id again as a Map report.
+			taskId = -1
+		case Reduce:
+			taskId = reply.Reduce.PartitionId
+			stopHeartbeat := startHeartbeat(clientId, taskId, Reduce)
+			doReduce(reducef, reply.Reduce, workDir)
+			stopHeartbeat()
+			callReduceDone(clientId, taskId)
+			taskId = -1
+		case Sleep:
+			time.Sleep(reply.Sleep.Backoff)
+		case Exit:
+			return
+		}
+	}
+}
+
+// startHeartbeat calls Heartbeat on a fixed interval until the returned
+// stop function is called, so the coordinator knows this worker is still
+// alive and making progress on the given task.
+func startHeartbeat(clientId, taskId int, phase WorkerStatus) func() {
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(heartbeatInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				args := HeartbeatArgs{ClientId: clientId, TaskId: taskId, Phase: phase}
+				call("Coordinator.Heartbeat", &args, &HeartbeatReply{})
+			case <-done:
+				return
+			}
+		}
+	}()
+	return func() { close(done) }
+}
+
+// doMap runs the map function over task's input file, partitions the
+// output into NReduce buckets, and writes each bucket to its own
+// intermediate file, returning where every partition ended up so it can
+// be reported back to the coordinator.
+func doMap(mapf func(string, string) []KeyValue, task *MapTaskPayload, selfHost, workDir string) []Location {
+
+	content, err := ioutil.ReadFile(task.InputFile)
+	if err != nil {
+		logFatal("❌ read map input", err)
+	}
+
+	kva := mapf(task.InputFile, string(content))
+
+	buckets := make([][]KeyValue, task.NReduce)
+	for _, kv := range kva {
+		r := ihash(kv.Key) % task.NReduce
+		buckets[r] = append(buckets[r], kv)
+	}
+
+	locations := make([]Location, task.NReduce)
+	for r, bucket := range buckets {
+		if len(bucket) == 0 {
+			continue
+		}
+		if task.HasCombiner {
+			bucket = applyCombiner(bucket)
+		}
+		// Name is scoped by attempt so a backup copy of this task
+		// racing the original never clobbers its output. A losing
+		// attempt's files are just left behind on this node; nothing
+		// currently cleans them up.
+		name := fmt.Sprintf("mr-%d-%d-%d", task.MapId, r, task.AttemptId)
+		path := workDir + "/" + name
+		writeIntermediate(path, bucket)
+		locations[r] = Location{MapId: task.MapId, Host: selfHost, Path: "/" + name}
+	}
+	return locations
+}
+
+func writeIntermediate(path string, kva []KeyValue) {
+	f, err := os.Create(path)
+	if err != nil {
+		logFatal("❌ create intermediate file", err)
+	}
+	defer f.Close()
+
+	enc := json.NewEncoder(f)
+	for _, kv := range kva {
+		if err := enc.Encode(&kv); err != nil {
+			logFatal("❌ encode intermediate kv", err)
+		}
+	}
+}
+
+// doReduce fetches every partition assigned to this reduce task (using
+// ShuffleTransport to pull from wherever the producing mapper put it),
+// groups by key, and writes the final mr-out file.
+func doReduce(reducef func(string, []string) string, task *ReduceTaskPayload, workDir string) {
+
+	var kva []KeyValue
+	cacheDir := workDir
+
+	for _, loc := range task.SourceLocations {
+		transport := pickTransport(loc, "", cacheDir)
+		path, err := transport.Fetch(loc)
+		if err != nil {
+			logFatal("❌ shuffle fetch", err)
+		}
+
+		f, err := os.Open(path)
+		if err != nil {
+			logFatal("❌ open partition", err)
+		}
+		dec := json.NewDecoder(f)
+		for {
+			var kv KeyValue
+			if err := dec.Decode(&kv); err != nil {
+				break
+			}
+			kva = append(kva, kv)
+		}
+		f.Close()
+	}
+
+	sort.Slice(kva, func(i, j int) bool { return kva[i].Key < kva[j].Key })
+
+	oname := fmt.Sprintf("mr-out-%d", task.PartitionId)
+	ofile, err := os.Create(oname)
+	if err != nil {
+		logFatal("❌ create reduce output", err)
+	}
+	defer ofile.Close()
+
+	i := 0
+	for i < len(kva) {
+		j := i + 1
+		for j < len(kva) && kva[j].Key == kva[i].Key {
+			j++
+		}
+		values := make([]string, 0, j-i)
+		for k := i; k < j; k++ {
+			values = append(values, kva[k].Value)
+		}
+		output := reducef(kva[i].Key, values)
+		fmt.Fprintf(ofile, "%v %v\n", kva[i].Key, output)
+		i = j
+	}
+}
+
+// callGetTask both reports the previous task's completion (if any) and
+// asks the coordinator what to do next. clientId is 0 until the
+// coordinator hands one out on this worker's first call.
+func callGetTask(clientId, taskId int, completed []Location) *TaskReply {
+	return callGetTaskAttempt(clientId, taskId, 0, completed)
+}
+
+// callReduceDone reports a finished reduce task. Unlike a map completion,
+// it carries no Location payload for callGetTaskAttempt to infer
+// WorkerStatus from, so it has to be set explicitly or the report goes
+// out as the zero value (Map) and the coordinator never decrements
+// reduceLeft.
+func callReduceDone(clientId, partitionId int) *TaskReply {
+	args := TaskArgs{ClientId: clientId, WorkerId: partitionId, WorkerStatus: Reduce}
+	reply := TaskReply{}
+
+	ok := call("Coordinator.GetTask", &args, &reply)
+	if !ok {
+		return nil
+	}
+	return &reply
+}
+
+// callGetTaskAttempt is callGetTask plus the attempt id of the task being
+// reported complete. The coordinator does not read the attempt id: it
+// accepts the first completion report for a task id and ignores later
+// ones, whichever attempt sent them.
+func callGetTaskAttempt(clientId, taskId, attemptId int, completed []Location) *TaskReply {
+
+	args := TaskArgs{ClientId: clientId, WorkerId: taskId, AttemptId: attemptId, CompletedTasks: completed}
+	if completed != nil {
+		args.WorkerStatus = Map
+	}
+	reply := TaskReply{}
+
+	ok := call("Coordinator.GetTask", &args, &reply)
+	if !ok {
+		return nil
+	}
+	return &reply
+}
+
+//
+// send an RPC request to the coordinator, wait for the response.
+// usually returns true.
+// returns false if something goes wrong.
+//
+func call(rpcname string, args interface{}, reply interface{}) bool {
+	sockname := coordinatorSock()
+	c, err := rpc.DialHTTP("unix", sockname)
+	if err != nil {
+		logFatal("❌ dialing", err)
+	}
+	defer c.Close()
+
+	err = c.Call(rpcname, args, reply)
+	if err == nil {
+		return true
+	}
+
+	fmt.Println("❌", err)
+	return false
+}
+
+func logFatal(msg string, err error) {
+	fmt.Println(msg, err)
+	os.Exit(1)
+}